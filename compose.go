@@ -0,0 +1,197 @@
+package dockertest
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v2"
+
+	"github.com/MOZGIII/abema-dockertest/errdefs"
+)
+
+// projectLabel tags every container started for a Compose project so the
+// whole project can be torn down together.
+const projectLabel = "dockertest.project"
+
+// ComposeService describes a single service of a Compose project, the
+// in-code equivalent of one entry under a compose file's "services" key.
+// Args, read from the "command" key, holds `docker run` options applied
+// the way Run applies its args; it does not set the container's command.
+type ComposeService struct {
+	Image string            `yaml:"image"`
+	Env   map[string]string `yaml:"environment"`
+	Args  []string          `yaml:"command"`
+}
+
+// ComposeFile is the in-code equivalent of a docker-compose YAML file,
+// supporting the small subset of the schema dockertest needs to start
+// services: image, environment and `docker run` options (under "command")
+// per service. A service's container command cannot be set from it.
+type ComposeFile struct {
+	Services map[string]ComposeService `yaml:"services"`
+}
+
+// LoadComposeFile reads and parses a compose YAML file at path.
+func LoadComposeFile(path string) (*ComposeFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errdefs.NotFound(fmt.Errorf("compose file %s not found: %w", path, err))
+		}
+		return nil, errdefs.System(fmt.Errorf("failed to read compose file %s: %w", path, err))
+	}
+	var f ComposeFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("failed to parse compose file %s: %w", path, err))
+	}
+	return &f, nil
+}
+
+// Compose is a running docker-compose-style project: a set of containers
+// started together on a shared project network (so services can reach
+// each other by service name) and labeled so they can be waited on
+// individually and torn down as a unit.
+type Compose struct {
+	client     *client.Client
+	project    string
+	networkID  string
+	Containers map[string]*Container
+}
+
+// ComposeUp starts every service in f and returns the running project.
+// Each container is labeled with a project identifier unique to this run
+// and joined to a project-private network under its service name, the
+// way a real docker-compose project resolves services by DNS name.
+func ComposeUp(f *ComposeFile) (*Compose, error) {
+	cli, err := Client()
+	if err != nil {
+		return nil, errdefs.System(fmt.Errorf("failed to get docker client: %w", err))
+	}
+
+	project, err := randomID()
+	if err != nil {
+		return nil, errdefs.System(fmt.Errorf("failed to generate compose project id: %w", err))
+	}
+
+	labels, err := withSessionLabel(map[string]string{projectLabel: project})
+	if err != nil {
+		return nil, errdefs.System(err)
+	}
+
+	netResp, err := cli.NetworkCreate(context.Background(), "dockertest-"+project, types.NetworkCreate{Labels: labels})
+	if err != nil {
+		return nil, errdefs.System(fmt.Errorf("failed to create network for compose project %s: %w", project, err))
+	}
+
+	cp := &Compose{
+		client:     cli,
+		project:    project,
+		networkID:  netResp.ID,
+		Containers: make(map[string]*Container, len(f.Services)),
+	}
+
+	for name, svc := range f.Services {
+		networkingConfig := &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				netResp.ID: {Aliases: []string{name}},
+			},
+		}
+		c, err := runContainer(cli, svc.Image, svc.Env, labels, nil, networkingConfig, svc.Args...)
+		if err != nil {
+			cp.Close() // nolint: errcheck
+			return nil, fmt.Errorf("failed to start service %s: %w", name, err)
+		}
+		cp.Containers[name] = c
+	}
+	return cp, nil
+}
+
+// ComposeUpFile loads path as a compose file and brings its services up.
+func ComposeUpFile(path string) (*Compose, error) {
+	f, err := LoadComposeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ComposeUp(f)
+}
+
+// Service returns the container started for the named service.
+func (cp *Compose) Service(name string) (*Container, error) {
+	c, ok := cp.Containers[name]
+	if !ok {
+		return nil, errdefs.NotFound(fmt.Errorf("service %s not found in compose project %s", name, cp.project))
+	}
+	return c, nil
+}
+
+// WaitService retries check against the named service's container using
+// the same exponential backoff as Container.Wait.
+func (cp *Compose) WaitService(name string, maxInterval, maxWait time.Duration, check func(*Container) error) error {
+	c, err := cp.Service(name)
+	if err != nil {
+		return err
+	}
+	return c.Wait(maxInterval, maxWait, func() error {
+		return check(c)
+	})
+}
+
+// WaitServiceFor blocks until s reports the named service's container
+// ready, the Compose counterpart of Container.WaitFor, e.g.
+// compose.WaitServiceFor(ctx, "db", ForListeningPort(5432)).
+func (cp *Compose) WaitServiceFor(ctx context.Context, name string, s WaitStrategy) error {
+	c, err := cp.Service(name)
+	if err != nil {
+		return err
+	}
+	return c.WaitFor(ctx, s)
+}
+
+// Close tears down every container in the project, including their
+// anonymous volumes, and removes the project network.
+func (cp *Compose) Close() error {
+	var firstErr error
+	for name, c := range cp.Containers {
+		if err := cp.closeContainer(c); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close service %s: %w", name, err)
+		}
+	}
+	if cp.networkID != "" {
+		if err := cp.client.NetworkRemove(context.Background(), cp.networkID); err != nil && firstErr == nil {
+			firstErr = errdefs.System(fmt.Errorf("failed to remove network for compose project %s: %w", cp.project, err))
+		}
+	}
+	return firstErr
+}
+
+// closeContainer stops and removes c, including its anonymous volumes,
+// unlike Container.Close which leaves volumes behind for callers that
+// manage a single container directly.
+func (cp *Compose) closeContainer(c *Container) error {
+	ctx := context.Background()
+	if err := c.client.ContainerStop(ctx, c.containerID, container.StopOptions{}); err != nil {
+		return errdefs.System(fmt.Errorf("failed to stop container %s: %w", c.containerID, err))
+	}
+	if err := c.client.ContainerRemove(ctx, c.containerID, types.ContainerRemoveOptions{RemoveVolumes: true}); err != nil {
+		return errdefs.System(fmt.Errorf("failed to remove container %s: %w", c.containerID, err))
+	}
+	return nil
+}
+
+// randomID generates a short hex identifier unique enough to tag a
+// project's containers without colliding with other concurrent runs.
+func randomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", b), nil
+}
@@ -1,27 +1,53 @@
 package dockertest
 
 import (
-	"bytes"
-	"errors"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
-	"regexp"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/cenk/backoff"
-)
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
 
-var (
-	portRegex = regexp.MustCompile(`([0-9]+)\/(.+?)\s\->.+?:([0-9]+)`)
+	"github.com/MOZGIII/abema-dockertest/errdefs"
 )
 
+var defaultClient *client.Client
+
+// NewClient builds a Docker Engine API client from the environment
+// (DOCKER_HOST, DOCKER_CERT_PATH, DOCKER_TLS_VERIFY), negotiating the
+// API version with the daemon.
+func NewClient() (*client.Client, error) {
+	return client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+}
+
+// Client returns the package-wide Docker Engine API client, creating it
+// on first use. Use it to reach the underlying engine client for
+// operations this package doesn't wrap directly.
+func Client() (*client.Client, error) {
+	if defaultClient != nil {
+		return defaultClient, nil
+	}
+	cli, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+	defaultClient = cli
+	return cli, nil
+}
+
 // Container is docker container instance.
 type Container struct {
+	client      *client.Client
 	containerID string
 	image       string
 	ports       map[int]int
@@ -35,26 +61,71 @@ func Run(image string, args ...string) (*Container, error) {
 }
 
 // RunEnvs image with environment variables and returns docker container.
+// args are additional `docker run` options applied before the image
+// (e.g. "-v", "/host:/container", "--network", "foo"), matching how the
+// pre-chunk0-1 CLI-based implementation treated them. Unrecognized
+// options return an errdefs.ErrInvalidParameter error.
 func RunEnvs(image string, envs map[string]string, args ...string) (*Container, error) {
-	cmdargs := []string{"run", "-P", "-d"}
+	cli, err := Client()
+	if err != nil {
+		return nil, errdefs.System(fmt.Errorf("failed to get docker client: %w", err))
+	}
+	labels, err := withSessionLabel(nil)
+	if err != nil {
+		return nil, errdefs.System(err)
+	}
+	return runContainer(cli, image, envs, labels, nil, nil, args...)
+}
 
-	// append environment variables
+// runContainer creates and starts a container for image, optionally
+// tagging it with labels (used by Compose to group a project's containers),
+// bind-mounting binds (used by the reaper to reach the docker socket), and
+// joining networkingConfig (used by Compose to give services a shared
+// project network and service-name DNS).
+func runContainer(cli *client.Client, image string, envs, labels map[string]string, binds []string, networkingConfig *network.NetworkingConfig, args ...string) (*Container, error) {
+	env := make([]string, 0, len(envs))
 	for k, v := range envs {
-		cmdargs = append(cmdargs, "-e", k+"="+v)
+		env = append(env, k+"="+v)
 	}
-	cmdargs = append(cmdargs, args...)
-	cmdargs = append(cmdargs, image)
 
-	// run and get containerID
-	containerID, err := run("docker", cmdargs...)
+	config := &container.Config{
+		Image:  image,
+		Env:    env,
+		Labels: labels,
+	}
+	hostConfig := &container.HostConfig{
+		PublishAllPorts: true,
+		Binds:           binds,
+	}
+	name, err := applyRunArgs(config, hostConfig, args)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	created, err := cli.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, name)
+	if client.IsErrNotFound(err) {
+		if pullErr := pullImage(ctx, cli, image); pullErr != nil {
+			return nil, errdefs.NotFound(fmt.Errorf("image %s not found locally and pull failed: %w", image, pullErr))
+		}
+		created, err = cli.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, name)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed run docker image:%s args:%v", image, args)
+		wrapped := fmt.Errorf("failed to create container for image:%s args:%v err:%w", image, args, err)
+		if client.IsErrNotFound(err) {
+			return nil, errdefs.NotFound(wrapped)
+		}
+		return nil, errdefs.System(wrapped)
+	}
+
+	if err := cli.ContainerStart(ctx, created.ID, types.ContainerStartOptions{}); err != nil {
+		return nil, errdefs.System(fmt.Errorf("failed to start container %s image:%s err:%w", created.ID, image, err))
 	}
 
-	// get port map
-	ports, err := run("docker", "port", containerID)
+	inspect, err := cli.ContainerInspect(ctx, created.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed get ports image:%s", image)
+		return nil, errdefs.System(fmt.Errorf("failed to inspect container %s image:%s err:%w", created.ID, image, err))
 	}
 
 	host := "127.0.0.1"
@@ -65,33 +136,50 @@ func RunEnvs(image string, envs map[string]string, args ...string) (*Container,
 	}
 
 	c := &Container{
-		containerID: containerID,
+		client:      cli,
+		containerID: created.ID,
 		image:       image,
 		host:        host,
 	}
-	c.parsePorts(ports)
+	c.parsePorts(inspect.NetworkSettings.Ports)
 	return c, nil
 }
 
-// Close docker container.
-func (c *Container) Close() error {
-	if _, err := run("docker", "stop", c.containerID); err != nil {
+// pullImage pulls image, mirroring the implicit pull `docker run` performs
+// for an image that isn't already cached on the daemon. ContainerCreate,
+// unlike the CLI, never pulls on its own.
+func pullImage(ctx context.Context, cli *client.Client, image string) error {
+	rc, err := cli.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
 		return err
 	}
-	// wait until docker stops ignoring the errors
-	run("docker", "wait", c.containerID) // nolint: errcheck
-	// remove the container
-	_, err := run("docker", "rm", c.containerID)
+	defer rc.Close() // nolint: errcheck
+	_, err = io.Copy(ioutil.Discard, rc)
 	return err
 }
 
+// Close docker container.
+func (c *Container) Close() error {
+	ctx := context.Background()
+	if err := c.client.ContainerStop(ctx, c.containerID, container.StopOptions{}); err != nil {
+		return errdefs.System(fmt.Errorf("failed to stop container %s: %w", c.containerID, err))
+	}
+	if err := c.client.ContainerRemove(ctx, c.containerID, types.ContainerRemoveOptions{}); err != nil {
+		return errdefs.System(fmt.Errorf("failed to remove container %s: %w", c.containerID, err))
+	}
+	return nil
+}
+
 // KillRemove kills and removes container.
 func (c *Container) KillRemove() error {
-	if _, err := run("docker", "kill", c.containerID); err != nil {
-		return err
+	ctx := context.Background()
+	if err := c.client.ContainerKill(ctx, c.containerID, "KILL"); err != nil {
+		return errdefs.System(fmt.Errorf("failed to kill container %s: %w", c.containerID, err))
 	}
-	_, err := run("docker", "rm", c.containerID)
-	return err
+	if err := c.client.ContainerRemove(ctx, c.containerID, types.ContainerRemoveOptions{}); err != nil {
+		return errdefs.System(fmt.Errorf("failed to remove container %s: %w", c.containerID, err))
+	}
+	return nil
 }
 
 // Host returns host IP which runs docker.
@@ -104,12 +192,12 @@ func (c *Container) WaitPort(port int, timeout time.Duration) (int, error) {
 	// wait until port available
 	p := c.ports[port]
 	if p == 0 {
-		return 0, fmt.Errorf("port %d is not exposed on %s", port, c.image)
+		return 0, errdefs.InvalidParameter(fmt.Errorf("port %d is not exposed on %s", port, c.image))
 	}
 
 	nw := c.networks[port]
 	if nw == "" {
-		return 0, fmt.Errorf("network not described on %s", c.image)
+		return 0, errdefs.InvalidParameter(fmt.Errorf("network not described on %s", c.image))
 	}
 
 	end := time.Now().Add(timeout)
@@ -118,7 +206,7 @@ func (c *Container) WaitPort(port int, timeout time.Duration) (int, error) {
 		_, err := net.DialTimeout(nw, c.Addr(port), end.Sub(now))
 		if err != nil {
 			if time.Now().After(end) {
-				return 0, fmt.Errorf("port %d not available on %s for %f seconds", port, c.image, timeout.Seconds())
+				return 0, errdefs.Timeout(fmt.Errorf("port %d not available on %s for %f seconds", port, c.image, timeout.Seconds()))
 			}
 			time.Sleep(time.Second)
 			continue
@@ -132,7 +220,7 @@ func (c *Container) WaitPort(port int, timeout time.Duration) (int, error) {
 func (c *Container) WaitHTTP(port int, path string, timeout time.Duration) (int, error) {
 	p := c.ports[port]
 	if p == 0 {
-		return 0, fmt.Errorf("port %d is not exposed on %s", port, c.image)
+		return 0, errdefs.InvalidParameter(fmt.Errorf("port %d is not exposed on %s", port, c.image))
 	}
 	now := time.Now()
 	end := now.Add(timeout)
@@ -141,7 +229,7 @@ func (c *Container) WaitHTTP(port int, path string, timeout time.Duration) (int,
 		res, err := cli.Get("http://" + c.Addr(port) + path)
 		if err != nil {
 			if time.Now().After(end) {
-				return 0, fmt.Errorf("http not available on port %d for %s err:%v", port, c.image, err)
+				return 0, errdefs.Timeout(fmt.Errorf("http not available on port %d for %s err:%w", port, c.image, err))
 			}
 			// sleep 1 sec to retry
 			time.Sleep(1 * time.Second)
@@ -150,7 +238,7 @@ func (c *Container) WaitHTTP(port int, path string, timeout time.Duration) (int,
 		defer res.Body.Close() // nolint: errcheck
 		if res.StatusCode < 200 || res.StatusCode >= 300 {
 			if time.Now().After(end) {
-				return 0, fmt.Errorf("http has not valid status code on port %d for %s code:%d", port, c.image, res.StatusCode)
+				return 0, errdefs.Unavailable(fmt.Errorf("http has not valid status code on port %d for %s code:%d", port, c.image, res.StatusCode))
 			}
 			// sleep 1 sec to retry
 			time.Sleep(1 * time.Second)
@@ -169,7 +257,10 @@ func (c *Container) Wait(maxInterval, maxWait time.Duration, check func() error)
 	bo := backoff.NewExponentialBackOff()
 	bo.MaxInterval = maxInterval
 	bo.MaxElapsedTime = maxWait
-	return backoff.Retry(check, bo)
+	if err := backoff.Retry(check, bo); err != nil {
+		return errdefs.Timeout(err)
+	}
+	return nil
 }
 
 // Port returns exposed port in docker host.
@@ -183,40 +274,19 @@ func (c *Container) Addr(port int) string {
 	return net.JoinHostPort(c.host, strconv.Itoa(exposed))
 }
 
-// run command and get result.
-func run(name string, args ...string) (out string, err error) {
-
-	cmd := exec.Command(name, args...)
+func (c *Container) parsePorts(bindings nat.PortMap) {
+	c.ports = make(map[int]int, len(bindings))
+	c.networks = make(map[int]string, len(bindings))
 
-	stdout := &bytes.Buffer{}
-	stderr := &bytes.Buffer{}
-
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
-
-	if err = cmd.Run(); err != nil {
-		return
-	}
-
-	if cmd.ProcessState.Success() {
-		return strings.TrimSpace(stdout.String()), nil
-	}
-
-	err = errors.New("command execution failed " + stderr.String())
-	return
-}
-
-func (c *Container) parsePorts(lines string) {
-
-	matches := portRegex.FindAllStringSubmatch(lines, -1)
-	c.ports = make(map[int]int, len(matches))
-	c.networks = make(map[int]string, len(matches))
-
-	for _, match := range matches {
-		p1, _ := strconv.Atoi(match[1])
-		p2, _ := strconv.Atoi(match[3])
-		c.ports[p1] = p2
-		c.networks[p1] = match[2]
+	for port, hostBindings := range bindings {
+		if len(hostBindings) == 0 {
+			continue
+		}
+		hostPort, err := strconv.Atoi(hostBindings[0].HostPort)
+		if err != nil {
+			continue
+		}
+		c.ports[port.Int()] = hostPort
+		c.networks[port.Int()] = port.Proto()
 	}
-
 }
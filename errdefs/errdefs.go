@@ -0,0 +1,134 @@
+// Package errdefs defines the set of error interfaces dockertest uses to
+// let callers react to specific failure modes (missing image, bad
+// argument, timeout, ...) without substring-matching error messages, in
+// the style of moby's api/errdefs package.
+package errdefs
+
+import "errors"
+
+// ErrNotFound indicates that whatever was looked up (image, compose file,
+// compose service) does not exist.
+type ErrNotFound interface {
+	NotFound() bool
+}
+
+// ErrInvalidParameter indicates that the caller passed a bad argument,
+// e.g. a port that was never exposed by the image.
+type ErrInvalidParameter interface {
+	InvalidParameter() bool
+}
+
+// ErrTimeout indicates that an operation did not complete within its
+// deadline.
+type ErrTimeout interface {
+	Timeout() bool
+}
+
+// ErrUnavailable indicates that the thing being waited on is reachable
+// but not yet (or no longer) in a usable state.
+type ErrUnavailable interface {
+	Unavailable() bool
+}
+
+// ErrSystem indicates an unexpected failure talking to the Docker
+// engine that doesn't fit any of the other categories.
+type ErrSystem interface {
+	System() bool
+}
+
+// NotFound wraps err as an ErrNotFound.
+func NotFound(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errNotFound{err}
+}
+
+// InvalidParameter wraps err as an ErrInvalidParameter.
+func InvalidParameter(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errInvalidParameter{err}
+}
+
+// Timeout wraps err as an ErrTimeout.
+func Timeout(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errTimeout{err}
+}
+
+// Unavailable wraps err as an ErrUnavailable.
+func Unavailable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errUnavailable{err}
+}
+
+// System wraps err as an ErrSystem.
+func System(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errSystem{err}
+}
+
+// IsNotFound reports whether err, or any error it wraps, is an ErrNotFound.
+func IsNotFound(err error) bool {
+	var e ErrNotFound
+	return errors.As(err, &e) && e.NotFound()
+}
+
+// IsInvalidParameter reports whether err, or any error it wraps, is an
+// ErrInvalidParameter.
+func IsInvalidParameter(err error) bool {
+	var e ErrInvalidParameter
+	return errors.As(err, &e) && e.InvalidParameter()
+}
+
+// IsTimeout reports whether err, or any error it wraps, is an ErrTimeout.
+func IsTimeout(err error) bool {
+	var e ErrTimeout
+	return errors.As(err, &e) && e.Timeout()
+}
+
+// IsUnavailable reports whether err, or any error it wraps, is an
+// ErrUnavailable.
+func IsUnavailable(err error) bool {
+	var e ErrUnavailable
+	return errors.As(err, &e) && e.Unavailable()
+}
+
+// IsSystem reports whether err, or any error it wraps, is an ErrSystem.
+func IsSystem(err error) bool {
+	var e ErrSystem
+	return errors.As(err, &e) && e.System()
+}
+
+type errNotFound struct{ error }
+
+func (e errNotFound) NotFound() bool { return true }
+func (e errNotFound) Unwrap() error  { return e.error }
+
+type errInvalidParameter struct{ error }
+
+func (e errInvalidParameter) InvalidParameter() bool { return true }
+func (e errInvalidParameter) Unwrap() error          { return e.error }
+
+type errTimeout struct{ error }
+
+func (e errTimeout) Timeout() bool { return true }
+func (e errTimeout) Unwrap() error { return e.error }
+
+type errUnavailable struct{ error }
+
+func (e errUnavailable) Unavailable() bool { return true }
+func (e errUnavailable) Unwrap() error     { return e.error }
+
+type errSystem struct{ error }
+
+func (e errSystem) System() bool  { return true }
+func (e errSystem) Unwrap() error { return e.error }
@@ -0,0 +1,67 @@
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWrapAndIs(t *testing.T) {
+	cause := errors.New("boom")
+
+	cases := []struct {
+		name string
+		wrap func(error) error
+		is   func(error) bool
+	}{
+		{"NotFound", NotFound, IsNotFound},
+		{"InvalidParameter", InvalidParameter, IsInvalidParameter},
+		{"Timeout", Timeout, IsTimeout},
+		{"Unavailable", Unavailable, IsUnavailable},
+		{"System", System, IsSystem},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.wrap(cause)
+			if !tc.is(err) {
+				t.Fatalf("expected %s(cause) to satisfy Is%s", tc.name, tc.name)
+			}
+			if !errors.Is(err, cause) {
+				t.Fatalf("expected errors.Is to reach the wrapped cause")
+			}
+			if errors.Unwrap(err) != cause {
+				t.Fatalf("expected errors.Unwrap to return the original cause")
+			}
+
+			for _, other := range cases {
+				if other.name == tc.name {
+					continue
+				}
+				if other.is(err) {
+					t.Fatalf("%s(cause) unexpectedly satisfies Is%s", tc.name, other.name)
+				}
+			}
+		})
+	}
+}
+
+func TestWrapNil(t *testing.T) {
+	for _, wrap := range []func(error) error{NotFound, InvalidParameter, Timeout, Unavailable, System} {
+		if err := wrap(nil); err != nil {
+			t.Fatalf("expected wrapping nil to return nil, got %v", err)
+		}
+	}
+}
+
+func TestIsReachesThroughFmtWrap(t *testing.T) {
+	cause := errors.New("no such image")
+	err := fmt.Errorf("failed to create container: %w", NotFound(cause))
+
+	if !IsNotFound(err) {
+		t.Fatalf("expected IsNotFound to see through an outer %%w wrap")
+	}
+	if !errors.Is(err, cause) {
+		t.Fatalf("expected errors.Is to reach the original cause through two layers of wrapping")
+	}
+}
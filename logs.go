@@ -0,0 +1,77 @@
+package dockertest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/MOZGIII/abema-dockertest/errdefs"
+)
+
+// LogOptions configures Container.Logs.
+type LogOptions struct {
+	ShowStdout bool
+	ShowStderr bool
+	Follow     bool
+	Since      time.Time
+	Tail       string
+}
+
+// Logs returns the raw, multiplexed engine-API log stream for the
+// container. The stream interleaves stdout and stderr framed per
+// stdcopy.StdCopy; use FollowLogs or stdcopy.StdCopy directly to split
+// them. Callers must close the returned reader.
+func (c *Container) Logs(ctx context.Context, opts LogOptions) (io.ReadCloser, error) {
+	dockerOpts := types.ContainerLogsOptions{
+		ShowStdout: opts.ShowStdout,
+		ShowStderr: opts.ShowStderr,
+		Follow:     opts.Follow,
+		Tail:       opts.Tail,
+	}
+	if !opts.Since.IsZero() {
+		dockerOpts.Since = opts.Since.Format(time.RFC3339Nano)
+	}
+	rc, err := c.client.ContainerLogs(ctx, c.containerID, dockerOpts)
+	if err != nil {
+		return nil, errdefs.System(fmt.Errorf("failed to get logs for container %s: %w", c.containerID, err))
+	}
+	return rc, nil
+}
+
+// FollowLogs streams the container's logs to stdout/stderr until ctx is
+// done or the container stops, demultiplexing the engine-API stream the
+// way `docker logs` does.
+func (c *Container) FollowLogs(ctx context.Context, stdout, stderr io.Writer) error {
+	rc, err := c.Logs(ctx, LogOptions{ShowStdout: true, ShowStderr: true, Follow: true})
+	if err != nil {
+		return err
+	}
+	defer rc.Close() // nolint: errcheck
+
+	if _, err := stdcopy.StdCopy(stdout, stderr, rc); err != nil {
+		return errdefs.System(fmt.Errorf("failed to stream logs for container %s: %w", c.containerID, err))
+	}
+	return nil
+}
+
+// LogsSince returns the container's combined stdout/stderr output
+// produced since t, handy for dumping context when a wait strategy times
+// out.
+func (c *Container) LogsSince(t time.Time) (string, error) {
+	rc, err := c.Logs(context.Background(), LogOptions{ShowStdout: true, ShowStderr: true, Since: t})
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close() // nolint: errcheck
+
+	var buf bytes.Buffer
+	if _, err := stdcopy.StdCopy(&buf, &buf, rc); err != nil {
+		return "", errdefs.System(fmt.Errorf("failed to read logs for container %s: %w", c.containerID, err))
+	}
+	return buf.String(), nil
+}
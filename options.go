@@ -0,0 +1,57 @@
+package dockertest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MOZGIII/abema-dockertest/errdefs"
+)
+
+// runOptions collects the settings RunOption functions configure.
+type runOptions struct {
+	envs map[string]string
+	args []string
+	wait WaitStrategy
+}
+
+// RunOption configures RunWithOptions.
+type RunOption func(*runOptions)
+
+// WithEnvs sets the environment variables passed to the container.
+func WithEnvs(envs map[string]string) RunOption {
+	return func(o *runOptions) { o.envs = envs }
+}
+
+// WithArgs sets additional `docker run` options applied before the
+// image (e.g. "-v", "/host:/container", "--network", "foo").
+func WithArgs(args ...string) RunOption {
+	return func(o *runOptions) { o.args = args }
+}
+
+// WithWaitStrategy makes RunWithOptions block until s reports the
+// container ready, removing it if it never becomes ready in time.
+func WithWaitStrategy(s WaitStrategy) RunOption {
+	return func(o *runOptions) { o.wait = s }
+}
+
+// RunWithOptions runs image configured by opts, optionally waiting for it
+// to become ready via WithWaitStrategy before returning it.
+func RunWithOptions(image string, opts ...RunOption) (*Container, error) {
+	var ro runOptions
+	for _, opt := range opts {
+		opt(&ro)
+	}
+
+	c, err := RunEnvs(image, ro.envs, ro.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if ro.wait != nil {
+		if err := c.WaitFor(context.Background(), ro.wait); err != nil {
+			c.KillRemove() // nolint: errcheck
+			return nil, errdefs.Timeout(fmt.Errorf("container for image %s did not become ready: %w", image, err))
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,156 @@
+package dockertest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/MOZGIII/abema-dockertest/errdefs"
+)
+
+// sessionLabel tags every container started in this process so they can
+// all be torn down together if the process dies without calling Close.
+const sessionLabel = "dockertest.session"
+
+// ReaperImage is the sidecar image spawned to clean up containers left
+// behind by a process that exits without closing them, modeled on
+// testcontainers-go's Ryuk. It watches the connection opened by
+// ensureReaper and removes everything carrying the session label once
+// that connection closes.
+var ReaperImage = "testcontainers/ryuk:0.8.1"
+
+var (
+	skipReaperMu sync.Mutex
+	skipReaper   bool
+
+	sessionOnce sync.Once
+	sessionID   string
+
+	reaperOnce sync.Once
+	reaperErr  error
+
+	// reaperConn is the connection ensureReaper opens to the ryuk
+	// sidecar. It is kept referenced here, rather than left as a local,
+	// so the runtime never closes it via GC finalization before the
+	// process exits: ryuk reaps the session the instant this connection
+	// closes, so an early close would reap live containers.
+	reaperConn net.Conn
+)
+
+// SkipReaper disables the reaper sidecar, for CI environments where a
+// Docker-in-Docker socket isn't wanted. It must be called before the
+// first Run/RunEnvs/ComposeUp.
+func SkipReaper() {
+	skipReaperMu.Lock()
+	defer skipReaperMu.Unlock()
+	skipReaper = true
+}
+
+func reaperSkipped() bool {
+	skipReaperMu.Lock()
+	defer skipReaperMu.Unlock()
+	return skipReaper
+}
+
+// currentSessionID returns the UUID-like identifier for this process,
+// generating it on first use.
+func currentSessionID() string {
+	sessionOnce.Do(func() {
+		id, err := randomID()
+		if err != nil {
+			// randomID only fails if crypto/rand is broken; fall back to
+			// a timestamp so labeling still degrades gracefully.
+			id = fmt.Sprintf("%x", time.Now().UnixNano())
+		}
+		sessionID = id
+	})
+	return sessionID
+}
+
+// withSessionLabel merges extra with the session label, starting the
+// reaper on first use unless SkipReaper was called.
+func withSessionLabel(extra map[string]string) (map[string]string, error) {
+	merged := make(map[string]string, len(extra)+1)
+	for k, v := range extra {
+		merged[k] = v
+	}
+	if reaperSkipped() {
+		return merged, nil
+	}
+	if err := ensureReaper(); err != nil {
+		return nil, fmt.Errorf("failed to start reaper (call dockertest.SkipReaper() to disable): %w", err)
+	}
+	merged[sessionLabel] = currentSessionID()
+	return merged, nil
+}
+
+// ensureReaper starts the reaper sidecar for this process, if one isn't
+// already running, and tells it to watch this process's session label.
+func ensureReaper() error {
+	reaperOnce.Do(func() {
+		cli, err := Client()
+		if err != nil {
+			reaperErr = err
+			return
+		}
+
+		c, err := runContainer(cli, ReaperImage, nil, map[string]string{"dockertest.reaper": "true"},
+			[]string{"/var/run/docker.sock:/var/run/docker.sock"}, nil)
+		if err != nil {
+			reaperErr = fmt.Errorf("failed to start reaper container: %w", err)
+			return
+		}
+
+		if _, err := c.WaitPort(8080, 30*time.Second); err != nil {
+			reaperErr = fmt.Errorf("reaper container never became ready: %w", err)
+			return
+		}
+
+		conn, err := net.Dial("tcp", c.Addr(8080))
+		if err != nil {
+			reaperErr = fmt.Errorf("failed to connect to reaper container: %w", err)
+			return
+		}
+
+		if _, err := fmt.Fprintf(conn, "label=%s=%s\n", sessionLabel, currentSessionID()); err != nil {
+			reaperErr = fmt.Errorf("failed to register session with reaper: %w", err)
+			return
+		}
+		// The reaper watches this connection: once it's closed (the
+		// process exited or panicked), it removes everything carrying
+		// our session label. Stash it in reaperConn so it stays open
+		// for the life of the process instead of being GC'd.
+		reaperConn = conn
+	})
+	return reaperErr
+}
+
+// Reap removes any containers left over from a previous, uncleanly
+// terminated run (e.g. a panicking test that skipped defer c.Close()).
+// Call it at the start of a test run.
+func Reap() error {
+	cli, err := Client()
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to get docker client: %w", err))
+	}
+
+	ctx := context.Background()
+	args := filters.NewArgs(filters.Arg("label", sessionLabel))
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to list leftover containers: %w", err))
+	}
+
+	var firstErr error
+	for _, ctr := range containers {
+		if err := cli.ContainerRemove(ctx, ctr.ID, types.ContainerRemoveOptions{Force: true}); err != nil && firstErr == nil {
+			firstErr = errdefs.System(fmt.Errorf("failed to remove leftover container %s: %w", ctr.ID, err))
+		}
+	}
+	return firstErr
+}
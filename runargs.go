@@ -0,0 +1,203 @@
+package dockertest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/MOZGIII/abema-dockertest/errdefs"
+)
+
+// runArgSpec describes how a single `docker run`-style flag is applied to
+// a container's config and host config, mirroring the subset of options
+// the CLI accepted in cmdargs before chunk0-1.
+type runArgSpec struct {
+	hasValue bool
+	apply    func(cfg *container.Config, host *container.HostConfig, value string)
+}
+
+var runArgSpecs = map[string]runArgSpec{
+	"-v": {hasValue: true, apply: func(_ *container.Config, host *container.HostConfig, v string) {
+		host.Binds = append(host.Binds, v)
+	}},
+	"--volume": {hasValue: true, apply: func(_ *container.Config, host *container.HostConfig, v string) {
+		host.Binds = append(host.Binds, v)
+	}},
+	"--network": {hasValue: true, apply: func(_ *container.Config, host *container.HostConfig, v string) {
+		host.NetworkMode = container.NetworkMode(v)
+	}},
+	"-e": {hasValue: true, apply: func(cfg *container.Config, _ *container.HostConfig, v string) {
+		cfg.Env = append(cfg.Env, v)
+	}},
+	"--env": {hasValue: true, apply: func(cfg *container.Config, _ *container.HostConfig, v string) {
+		cfg.Env = append(cfg.Env, v)
+	}},
+	"-w": {hasValue: true, apply: func(cfg *container.Config, _ *container.HostConfig, v string) {
+		cfg.WorkingDir = v
+	}},
+	"--workdir": {hasValue: true, apply: func(cfg *container.Config, _ *container.HostConfig, v string) {
+		cfg.WorkingDir = v
+	}},
+	"-u": {hasValue: true, apply: func(cfg *container.Config, _ *container.HostConfig, v string) {
+		cfg.User = v
+	}},
+	"--user": {hasValue: true, apply: func(cfg *container.Config, _ *container.HostConfig, v string) {
+		cfg.User = v
+	}},
+	"--entrypoint": {hasValue: true, apply: func(cfg *container.Config, _ *container.HostConfig, v string) {
+		cfg.Entrypoint = strslice.StrSlice{v}
+	}},
+	"-h": {hasValue: true, apply: func(cfg *container.Config, _ *container.HostConfig, v string) {
+		cfg.Hostname = v
+	}},
+	"--hostname": {hasValue: true, apply: func(cfg *container.Config, _ *container.HostConfig, v string) {
+		cfg.Hostname = v
+	}},
+	"--link": {hasValue: true, apply: func(_ *container.Config, host *container.HostConfig, v string) {
+		host.Links = append(host.Links, v)
+	}},
+	"--cap-add": {hasValue: true, apply: func(_ *container.Config, host *container.HostConfig, v string) {
+		host.CapAdd = append(host.CapAdd, v)
+	}},
+	"--cap-drop": {hasValue: true, apply: func(_ *container.Config, host *container.HostConfig, v string) {
+		host.CapDrop = append(host.CapDrop, v)
+	}},
+	"--privileged": {hasValue: false, apply: func(_ *container.Config, host *container.HostConfig, _ string) {
+		host.Privileged = true
+	}},
+	"-i": {hasValue: false, apply: func(cfg *container.Config, _ *container.HostConfig, _ string) {
+		cfg.OpenStdin = true
+	}},
+	"--interactive": {hasValue: false, apply: func(cfg *container.Config, _ *container.HostConfig, _ string) {
+		cfg.OpenStdin = true
+	}},
+	"-t": {hasValue: false, apply: func(cfg *container.Config, _ *container.HostConfig, _ string) {
+		cfg.Tty = true
+	}},
+	"--tty": {hasValue: false, apply: func(cfg *container.Config, _ *container.HostConfig, _ string) {
+		cfg.Tty = true
+	}},
+	"-it": {hasValue: false, apply: func(cfg *container.Config, _ *container.HostConfig, _ string) {
+		cfg.OpenStdin = true
+		cfg.Tty = true
+	}},
+	"--add-host": {hasValue: true, apply: func(_ *container.Config, host *container.HostConfig, v string) {
+		host.ExtraHosts = append(host.ExtraHosts, v)
+	}},
+	"--dns": {hasValue: true, apply: func(_ *container.Config, host *container.HostConfig, v string) {
+		host.DNS = append(host.DNS, v)
+	}},
+	"--dns-search": {hasValue: true, apply: func(_ *container.Config, host *container.HostConfig, v string) {
+		host.DNSSearch = append(host.DNSSearch, v)
+	}},
+	"--tmpfs": {hasValue: true, apply: func(_ *container.Config, host *container.HostConfig, v string) {
+		path, opts := v, ""
+		if idx := strings.IndexByte(v, ':'); idx >= 0 {
+			path, opts = v[:idx], v[idx+1:]
+		}
+		if host.Tmpfs == nil {
+			host.Tmpfs = make(map[string]string)
+		}
+		host.Tmpfs[path] = opts
+	}},
+	"--restart": {hasValue: true, apply: func(_ *container.Config, host *container.HostConfig, v string) {
+		name, retries := v, 0
+		if idx := strings.IndexByte(v, ':'); idx >= 0 {
+			name = v[:idx]
+			retries, _ = strconv.Atoi(v[idx+1:])
+		}
+		host.RestartPolicy = container.RestartPolicy{Name: name, MaximumRetryCount: retries}
+	}},
+}
+
+// applyRunArgs parses args as `docker run`-style options (as the CLI
+// accepted before chunk0-1, e.g. "-v", "/host:/container", "--network",
+// "foo") and applies them to cfg/host, returning the container name set
+// via --name/-n, if any. It does not touch cfg.Cmd: args are options
+// inserted before the image, not the container's command.
+//
+// -p/--publish is handled separately from runArgSpecs because it needs
+// nat.ParsePortSpecs to fill in both cfg.ExposedPorts and
+// host.PortBindings from possibly several accumulated specs.
+func applyRunArgs(cfg *container.Config, host *container.HostConfig, args []string) (string, error) {
+	var name string
+	var publishSpecs []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		argName, value, hasInlineValue := arg, "", false
+		if idx := strings.IndexByte(arg, '='); idx >= 0 {
+			argName, value, hasInlineValue = arg[:idx], arg[idx+1:], true
+		}
+
+		if argName == "--name" || argName == "-n" {
+			v, err := runArgValue(args, &i, arg, value, hasInlineValue)
+			if err != nil {
+				return "", err
+			}
+			name = v
+			continue
+		}
+		if argName == "-p" || argName == "--publish" {
+			v, err := runArgValue(args, &i, arg, value, hasInlineValue)
+			if err != nil {
+				return "", err
+			}
+			publishSpecs = append(publishSpecs, v)
+			continue
+		}
+
+		spec, ok := runArgSpecs[argName]
+		if !ok {
+			return "", errdefs.InvalidParameter(fmt.Errorf("unsupported docker run option %q", arg))
+		}
+		if !spec.hasValue {
+			spec.apply(cfg, host, "")
+			continue
+		}
+		v, err := runArgValue(args, &i, arg, value, hasInlineValue)
+		if err != nil {
+			return "", err
+		}
+		spec.apply(cfg, host, v)
+	}
+
+	if len(publishSpecs) > 0 {
+		exposed, bindings, err := nat.ParsePortSpecs(publishSpecs)
+		if err != nil {
+			return "", errdefs.InvalidParameter(fmt.Errorf("invalid -p/--publish spec: %w", err))
+		}
+		if cfg.ExposedPorts == nil {
+			cfg.ExposedPorts = nat.PortSet{}
+		}
+		for p := range exposed {
+			cfg.ExposedPorts[p] = struct{}{}
+		}
+		if host.PortBindings == nil {
+			host.PortBindings = nat.PortMap{}
+		}
+		for p, b := range bindings {
+			host.PortBindings[p] = append(host.PortBindings[p], b...)
+		}
+	}
+
+	return name, nil
+}
+
+// runArgValue resolves the value for a value-taking flag, either inline
+// (--flag=value) or as the next token (--flag value), advancing *i past
+// whichever tokens it consumes.
+func runArgValue(args []string, i *int, arg, inlineValue string, hasInlineValue bool) (string, error) {
+	if hasInlineValue {
+		return inlineValue, nil
+	}
+	*i++
+	if *i >= len(args) {
+		return "", errdefs.InvalidParameter(fmt.Errorf("docker run option %q requires a value", arg))
+	}
+	return args[*i], nil
+}
@@ -0,0 +1,124 @@
+package dockertest
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/MOZGIII/abema-dockertest/errdefs"
+)
+
+func TestApplyRunArgs(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want func(t *testing.T, cfg *container.Config, host *container.HostConfig, containerName string)
+	}{
+		{
+			name: "volume",
+			args: []string{"-v", "/host:/container"},
+			want: func(t *testing.T, _ *container.Config, host *container.HostConfig, _ string) {
+				if len(host.Binds) != 1 || host.Binds[0] != "/host:/container" {
+					t.Fatalf("unexpected Binds: %v", host.Binds)
+				}
+			},
+		},
+		{
+			name: "network",
+			args: []string{"--network", "mynet"},
+			want: func(t *testing.T, _ *container.Config, host *container.HostConfig, _ string) {
+				if host.NetworkMode != container.NetworkMode("mynet") {
+					t.Fatalf("unexpected NetworkMode: %v", host.NetworkMode)
+				}
+			},
+		},
+		{
+			name: "env inline value",
+			args: []string{"-e=FOO=bar"},
+			want: func(t *testing.T, cfg *container.Config, _ *container.HostConfig, _ string) {
+				if len(cfg.Env) != 1 || cfg.Env[0] != "FOO=bar" {
+					t.Fatalf("unexpected Env: %v", cfg.Env)
+				}
+			},
+		},
+		{
+			name: "name",
+			args: []string{"--name", "web-1"},
+			want: func(t *testing.T, _ *container.Config, _ *container.HostConfig, containerName string) {
+				if containerName != "web-1" {
+					t.Fatalf("unexpected container name: %q", containerName)
+				}
+			},
+		},
+		{
+			name: "publish",
+			args: []string{"-p", "8080:80"},
+			want: func(t *testing.T, cfg *container.Config, host *container.HostConfig, _ string) {
+				port := nat.Port("80/tcp")
+				if _, ok := cfg.ExposedPorts[port]; !ok {
+					t.Fatalf("expected %s to be exposed, got %v", port, cfg.ExposedPorts)
+				}
+				bindings := host.PortBindings[port]
+				if len(bindings) != 1 || bindings[0].HostPort != "8080" {
+					t.Fatalf("unexpected PortBindings: %v", host.PortBindings)
+				}
+			},
+		},
+		{
+			name: "restart with retries",
+			args: []string{"--restart", "on-failure:5"},
+			want: func(t *testing.T, _ *container.Config, host *container.HostConfig, _ string) {
+				if host.RestartPolicy.Name != "on-failure" || host.RestartPolicy.MaximumRetryCount != 5 {
+					t.Fatalf("unexpected RestartPolicy: %+v", host.RestartPolicy)
+				}
+			},
+		},
+		{
+			name: "tmpfs with options",
+			args: []string{"--tmpfs", "/tmp:rw,size=64m"},
+			want: func(t *testing.T, _ *container.Config, host *container.HostConfig, _ string) {
+				if host.Tmpfs["/tmp"] != "rw,size=64m" {
+					t.Fatalf("unexpected Tmpfs: %v", host.Tmpfs)
+				}
+			},
+		},
+		{
+			name: "it shorthand",
+			args: []string{"-it"},
+			want: func(t *testing.T, cfg *container.Config, _ *container.HostConfig, _ string) {
+				if !cfg.OpenStdin || !cfg.Tty {
+					t.Fatalf("expected -it to set OpenStdin and Tty, got %+v", cfg)
+				}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &container.Config{}
+			host := &container.HostConfig{}
+			name, err := applyRunArgs(cfg, host, tc.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tc.want(t, cfg, host, name)
+		})
+	}
+}
+
+func TestApplyRunArgsErrors(t *testing.T) {
+	t.Run("unsupported option", func(t *testing.T) {
+		_, err := applyRunArgs(&container.Config{}, &container.HostConfig{}, []string{"--bogus"})
+		if !errdefs.IsInvalidParameter(err) {
+			t.Fatalf("expected ErrInvalidParameter, got %v", err)
+		}
+	})
+
+	t.Run("missing value", func(t *testing.T) {
+		_, err := applyRunArgs(&container.Config{}, &container.HostConfig{}, []string{"-v"})
+		if !errdefs.IsInvalidParameter(err) {
+			t.Fatalf("expected ErrInvalidParameter, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,266 @@
+package dockertest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/MOZGIII/abema-dockertest/errdefs"
+)
+
+// WaitStrategy expresses "wait until this container is truly ready" as a
+// single composable unit, instead of callers writing custom retry loops
+// around WaitPort/WaitHTTP.
+type WaitStrategy interface {
+	WaitUntilReady(ctx context.Context, c *Container) error
+}
+
+// WaitFor blocks until s reports the container ready or gives up. The
+// HTTP, log and exec strategies also return once ctx is done;
+// ForListeningPort does not watch ctx and runs until its own timeout.
+func (c *Container) WaitFor(ctx context.Context, s WaitStrategy) error {
+	return s.WaitUntilReady(ctx, c)
+}
+
+// portWaitStrategy waits for a container port to accept TCP connections.
+type portWaitStrategy struct {
+	port    int
+	timeout time.Duration
+}
+
+// ForListeningPort waits until port is exposed and accepting connections.
+func ForListeningPort(port int) *portWaitStrategy {
+	return &portWaitStrategy{port: port, timeout: time.Minute}
+}
+
+// WithTimeout overrides the default one minute wait timeout.
+func (s *portWaitStrategy) WithTimeout(timeout time.Duration) *portWaitStrategy {
+	s.timeout = timeout
+	return s
+}
+
+func (s *portWaitStrategy) WaitUntilReady(ctx context.Context, c *Container) error {
+	_, err := c.WaitPort(s.port, s.timeout)
+	return err
+}
+
+// httpWaitStrategy waits for an HTTP endpoint to return a matching status.
+type httpWaitStrategy struct {
+	port          int
+	path          string
+	headers       map[string]string
+	statusMatcher func(int) bool
+	timeout       time.Duration
+	pollInterval  time.Duration
+}
+
+// ForHTTP waits until an HTTP GET to path on port succeeds with a 2xx
+// status, by default.
+func ForHTTP(port int, path string) *httpWaitStrategy {
+	return &httpWaitStrategy{
+		port:          port,
+		path:          path,
+		statusMatcher: func(code int) bool { return code >= 200 && code < 300 },
+		timeout:       time.Minute,
+		pollInterval:  time.Second,
+	}
+}
+
+// WithStatusCodeMatcher overrides the default 2xx status matcher.
+func (s *httpWaitStrategy) WithStatusCodeMatcher(matcher func(int) bool) *httpWaitStrategy {
+	s.statusMatcher = matcher
+	return s
+}
+
+// WithHeaders sets request headers to send with every probe.
+func (s *httpWaitStrategy) WithHeaders(headers map[string]string) *httpWaitStrategy {
+	s.headers = headers
+	return s
+}
+
+// WithTimeout overrides the default one minute wait timeout.
+func (s *httpWaitStrategy) WithTimeout(timeout time.Duration) *httpWaitStrategy {
+	s.timeout = timeout
+	return s
+}
+
+func (s *httpWaitStrategy) WaitUntilReady(ctx context.Context, c *Container) error {
+	end := time.Now().Add(s.timeout)
+	url := "http://" + c.Addr(s.port) + s.path
+	for {
+		err := s.probe(ctx, url)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(end) {
+			return errdefs.Timeout(fmt.Errorf("http wait strategy on port %d path %s: %w", s.port, s.path, err))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+func (s *httpWaitStrategy) probe(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close() // nolint: errcheck
+	if !s.statusMatcher(res.StatusCode) {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	return nil
+}
+
+// logWaitStrategy waits until a container's combined log output matches
+// pattern at least occurrences times.
+type logWaitStrategy struct {
+	pattern      *regexp.Regexp
+	occurrences  int
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+// ForLog waits until the container's logs match pattern at least
+// occurrences times.
+func ForLog(pattern string, occurrences int) *logWaitStrategy {
+	return &logWaitStrategy{
+		pattern:      regexp.MustCompile(pattern),
+		occurrences:  occurrences,
+		timeout:      time.Minute,
+		pollInterval: time.Second,
+	}
+}
+
+// WithTimeout overrides the default one minute wait timeout.
+func (s *logWaitStrategy) WithTimeout(timeout time.Duration) *logWaitStrategy {
+	s.timeout = timeout
+	return s
+}
+
+func (s *logWaitStrategy) WaitUntilReady(ctx context.Context, c *Container) error {
+	end := time.Now().Add(s.timeout)
+	for {
+		matched, err := s.matches(ctx, c)
+		if err == nil && matched {
+			return nil
+		}
+		if time.Now().After(end) {
+			return errdefs.Timeout(fmt.Errorf("log wait strategy %s on %s: %w", s.pattern, c.image, err))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+func (s *logWaitStrategy) matches(ctx context.Context, c *Container) (bool, error) {
+	rc, err := c.Logs(ctx, LogOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close() // nolint: errcheck
+
+	var buf bytes.Buffer
+	if _, err := stdcopy.StdCopy(&buf, &buf, rc); err != nil {
+		return false, err
+	}
+	return len(s.pattern.FindAll(buf.Bytes(), -1)) >= s.occurrences, nil
+}
+
+// execWaitStrategy waits until a command run via docker exec exits zero.
+type execWaitStrategy struct {
+	cmd          []string
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+// ForExec waits until running cmd inside the container exits zero.
+func ForExec(cmd []string) *execWaitStrategy {
+	return &execWaitStrategy{cmd: cmd, timeout: time.Minute, pollInterval: time.Second}
+}
+
+// WithTimeout overrides the default one minute wait timeout.
+func (s *execWaitStrategy) WithTimeout(timeout time.Duration) *execWaitStrategy {
+	s.timeout = timeout
+	return s
+}
+
+func (s *execWaitStrategy) WaitUntilReady(ctx context.Context, c *Container) error {
+	end := time.Now().Add(s.timeout)
+	for {
+		exitCode, err := s.run(ctx, c)
+		if err == nil && exitCode == 0 {
+			return nil
+		}
+		if time.Now().After(end) {
+			return errdefs.Timeout(fmt.Errorf("exec wait strategy %v on %s: %w", s.cmd, c.image, err))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+func (s *execWaitStrategy) run(ctx context.Context, c *Container) (int, error) {
+	created, err := c.client.ContainerExecCreate(ctx, c.containerID, types.ExecConfig{Cmd: s.cmd})
+	if err != nil {
+		return 0, err
+	}
+	if err := c.client.ContainerExecStart(ctx, created.ID, types.ExecStartCheck{Detach: true}); err != nil {
+		return 0, err
+	}
+	for {
+		inspect, err := c.client.ContainerExecInspect(ctx, created.ID)
+		if err != nil {
+			return 0, err
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// allWaitStrategy waits for every strategy in order.
+type allWaitStrategy struct {
+	strategies []WaitStrategy
+}
+
+// ForAll composes strategies, waiting for each in order.
+func ForAll(strategies ...WaitStrategy) *allWaitStrategy {
+	return &allWaitStrategy{strategies: strategies}
+}
+
+func (s *allWaitStrategy) WaitUntilReady(ctx context.Context, c *Container) error {
+	for _, strategy := range s.strategies {
+		if err := strategy.WaitUntilReady(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}